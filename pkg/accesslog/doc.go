@@ -0,0 +1,21 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package accesslog emits structured log entries for PortRuleHTTP match
+// decisions, in particular the MismatchAction=LOG path of HeaderMatch. It
+// applies the redaction and client-IP obfuscation configured by a rule's
+// api.LogConfig before handing entries to a pluggable Sink (stdout-JSON,
+// rotating file, or a gRPC stream compatible with Envoy's Access Log
+// Service).
+package accesslog
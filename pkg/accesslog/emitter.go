@@ -0,0 +1,122 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"time"
+
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// Emitter applies a PortRuleHTTP's LogConfig (redaction, client IP
+// obfuscation) to raw decision data and forwards the resulting Entry to a
+// Sink.
+type Emitter struct {
+	sink Sink
+}
+
+// NewEmitter returns an Emitter delivering entries to sink.
+func NewEmitter(sink Sink) *Emitter {
+	return &Emitter{sink: sink}
+}
+
+// Record builds and emits an Entry for one rule evaluation. claims and
+// clientIP are the raw (unredacted, unobfuscated) values observed for the
+// request; cfg may be nil, in which case no redaction or obfuscation is
+// applied.
+func (e *Emitter) Record(ctx context.Context, ruleID uint16, decision Decision, latency time.Duration, mismatchedHeaders []string, claims map[string]string, clientIP string, cfg *api.LogConfig) error {
+	entry := Entry{
+		Time:              time.Now(),
+		RuleID:            ruleID,
+		Decision:          decision,
+		Latency:           latency,
+		MismatchedHeaders: mismatchedHeaders,
+		Claims:            redactClaims(claims, cfg),
+		ClientIP:          obfuscateIP(clientIP, cfg),
+	}
+	return e.sink.Emit(ctx, entry)
+}
+
+func (e *Emitter) Close() error {
+	return e.sink.Close()
+}
+
+// redactSet returns the set of names cfg.Redact lists, or nil if cfg is
+// unset.
+func redactSet(cfg *api.LogConfig) map[string]struct{} {
+	if cfg == nil || len(cfg.Redact) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(cfg.Redact))
+	for _, name := range cfg.Redact {
+		set[name] = struct{}{}
+	}
+	return set
+}
+
+// redactClaims replaces the value of any claim named in cfg.Redact with a
+// truncated SHA-256 digest (see hashValue), leaving all other claims
+// untouched.
+func redactClaims(claims map[string]string, cfg *api.LogConfig) map[string]string {
+	if len(claims) == 0 {
+		return nil
+	}
+	redact := redactSet(cfg)
+
+	out := make(map[string]string, len(claims))
+	for name, value := range claims {
+		if _, ok := redact[name]; ok {
+			out[name] = hashValue(value)
+		} else {
+			out[name] = value
+		}
+	}
+	return out
+}
+
+// hashValue returns a short, non-reversible digest of value so that
+// redacted entries remain correlatable (the same input always hashes the
+// same) without revealing the original value in the log.
+func hashValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// obfuscateIP masks ip per cfg.ObfuscateClientIP: IPv4 addresses are
+// truncated to their /24 network, IPv6 addresses to their /96 network, for
+// GDPR-style data minimization. ip is returned unchanged if cfg does not
+// request obfuscation or ip cannot be parsed.
+func obfuscateIP(ip string, cfg *api.LogConfig) string {
+	if cfg == nil || !cfg.ObfuscateClientIP || ip == "" {
+		return ip
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		mask := net.CIDRMask(24, 32)
+		return v4.Mask(mask).String()
+	}
+
+	mask := net.CIDRMask(96, 128)
+	return parsed.Mask(mask).String()
+}
@@ -0,0 +1,55 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import "time"
+
+// Decision is the outcome of evaluating a PortRuleHTTP against a request.
+type Decision string
+
+const (
+	DecisionForwarded Decision = "forwarded"
+	DecisionDenied    Decision = "denied"
+	DecisionMismatch  Decision = "mismatch" // AuditMode or MismatchAction=LOG: logged but not denied
+)
+
+// Entry is a single structured access log record.
+type Entry struct {
+	// Time is when the decision was made.
+	Time time.Time `json:"time"`
+
+	// RuleID is the PortRuleHTTP.RuleID the decision was made for.
+	RuleID uint16 `json:"ruleID"`
+
+	// Decision is the overall outcome for the request.
+	Decision Decision `json:"decision"`
+
+	// Latency is how long evaluating the rule (including any JWT
+	// verification, CEL evaluation, and rate-limit check) took.
+	Latency time.Duration `json:"latency"`
+
+	// MismatchedHeaders lists the names of HeaderMatches that did not
+	// match. Values are never logged here, only names, regardless of
+	// redaction.
+	MismatchedHeaders []string `json:"mismatchedHeaders,omitempty"`
+
+	// Claims holds the subset of verified JWT claims worth recording
+	// (e.g. "sub", "iss"), after redaction has been applied.
+	Claims map[string]string `json:"claims,omitempty"`
+
+	// ClientIP is the request's source IP, obfuscated per LogConfig when
+	// configured.
+	ClientIP string `json:"clientIP,omitempty"`
+}
@@ -0,0 +1,112 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileSinkConfig configures NewFileSink.
+type FileSinkConfig struct {
+	// Path is the log file to write to.
+	Path string
+
+	// MaxSizeBytes rotates the file (renaming it to Path+".1" and
+	// reopening Path) before the next write once it has reached this size,
+	// so the file may exceed it by up to one entry. Zero disables rotation.
+	MaxSizeBytes int64
+}
+
+// fileSink is a Sink writing newline-delimited JSON to a file, rotating it
+// by renaming the current file aside once it has reached MaxSizeBytes.
+type fileSink struct {
+	cfg FileSinkConfig
+
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	size int64
+}
+
+// NewFileSink opens (creating if necessary) the file at cfg.Path and
+// returns a Sink that appends to it, rotating per cfg.MaxSizeBytes.
+func NewFileSink(cfg FileSinkConfig) (Sink, error) {
+	s := &fileSink{cfg: cfg}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening access log file %s: %w", s.cfg.Path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("statting access log file %s: %w", s.cfg.Path, err)
+	}
+	s.file = f
+	s.enc = json.NewEncoder(f)
+	s.size = info.Size()
+	return nil
+}
+
+func (s *fileSink) Emit(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.rotateIfNeededLocked(); err != nil {
+		return err
+	}
+
+	before := s.size
+	if err := s.enc.Encode(entry); err != nil {
+		return fmt.Errorf("writing access log entry to %s: %w", s.cfg.Path, err)
+	}
+	info, err := s.file.Stat()
+	if err == nil {
+		s.size = info.Size()
+	} else {
+		s.size = before
+	}
+	return nil
+}
+
+func (s *fileSink) rotateIfNeededLocked() error {
+	if s.cfg.MaxSizeBytes <= 0 || s.size < s.cfg.MaxSizeBytes {
+		return nil
+	}
+
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("closing access log file %s before rotation: %w", s.cfg.Path, err)
+	}
+	if err := os.Rename(s.cfg.Path, s.cfg.Path+".1"); err != nil {
+		return fmt.Errorf("rotating access log file %s: %w", s.cfg.Path, err)
+	}
+	return s.open()
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,127 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	accesslogdata "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	accessloggrpc "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcSink streams entries as Envoy Access Log Service (ALS)
+// HTTPAccessLogEntry messages over a long-lived gRPC stream. A failed send
+// is returned to the caller and the stream is reopened on the next Emit.
+type grpcSink struct {
+	logName string
+
+	mu     sync.Mutex
+	conn   *grpc.ClientConn
+	client accessloggrpc.AccessLogServiceClient
+	stream accessloggrpc.AccessLogService_StreamAccessLogsClient
+}
+
+// NewGRPCSink dials target (an Envoy ALS-compatible collector) and returns
+// a Sink streaming entries to it under the given log name.
+func NewGRPCSink(target, logName string) (Sink, error) {
+	conn, err := grpc.Dial(target, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("dialing access log collector %s: %w", target, err)
+	}
+	return &grpcSink{
+		logName: logName,
+		conn:    conn,
+		client:  accessloggrpc.NewAccessLogServiceClient(conn),
+	}, nil
+}
+
+func (s *grpcSink) Emit(ctx context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stream == nil {
+		stream, err := s.client.StreamAccessLogs(ctx)
+		if err != nil {
+			return fmt.Errorf("opening access log stream: %w", err)
+		}
+		s.stream = stream
+	}
+
+	msg := &accessloggrpc.StreamAccessLogsMessage{
+		Identifier: &accessloggrpc.StreamAccessLogsMessage_Identifier{LogName: s.logName},
+		LogEntries: &accessloggrpc.StreamAccessLogsMessage_HttpLogs{
+			HttpLogs: &accessloggrpc.StreamAccessLogsMessage_HTTPAccessLogEntries{
+				LogEntry: []*accesslogdata.HTTPAccessLogEntry{toALSEntry(entry)},
+			},
+		},
+	}
+
+	if err := s.stream.Send(msg); err != nil {
+		// The stream is broken; drop it so the next Emit reconnects rather
+		// than repeatedly erroring on a dead stream.
+		s.stream = nil
+		return fmt.Errorf("sending access log entry: %w", err)
+	}
+	return nil
+}
+
+func (s *grpcSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream != nil {
+		_ = s.stream.CloseSend()
+	}
+	return s.conn.Close()
+}
+
+// toALSEntry maps an Entry onto the Envoy ALS HTTPAccessLogEntry message.
+// ALS has no first-class field for Cilium's ruleID, match decision,
+// mismatched header names, or verified JWT claims, so those are carried as
+// CustomTags (ALS's supported extension point for arbitrary metadata)
+// rather than dropped, keeping this sink on par with the stdout/file sinks
+// that serialize the full Entry.
+func toALSEntry(e Entry) *accesslogdata.HTTPAccessLogEntry {
+	tags := map[string]string{
+		"cilium.rule_id":  strconv.FormatUint(uint64(e.RuleID), 10),
+		"cilium.decision": string(e.Decision),
+	}
+	if len(e.MismatchedHeaders) > 0 {
+		tags["cilium.mismatched_headers"] = strings.Join(e.MismatchedHeaders, ",")
+	}
+	if e.ClientIP != "" {
+		tags["cilium.client_ip"] = e.ClientIP
+	}
+	if len(e.Claims) > 0 {
+		if encoded, err := json.Marshal(e.Claims); err == nil {
+			tags["cilium.claims"] = string(encoded)
+		}
+	}
+
+	return &accesslogdata.HTTPAccessLogEntry{
+		CommonProperties: &accesslogdata.AccessLogCommon{
+			StartTime:        timestamppb.New(e.Time),
+			TimeToLastRxByte: durationpb.New(e.Latency),
+			CustomTags:       tags,
+		},
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Sink receives access log entries after redaction/obfuscation has been
+// applied by Emitter. Implementations must be safe for concurrent use.
+type Sink interface {
+	// Emit delivers entry to the sink. Implementations should not block
+	// the caller indefinitely; a slow or unavailable sink must not stall
+	// the proxy's request path.
+	Emit(ctx context.Context, entry Entry) error
+
+	// Close releases any resources held by the sink (open files,
+	// connections, background goroutines).
+	Close() error
+}
+
+// jsonSink writes one JSON object per line to an io.Writer, used by
+// NewStdoutSink.
+type jsonSink struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+func (s *jsonSink) Emit(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(entry)
+}
+
+func (s *jsonSink) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// NewStdoutSink returns a Sink that writes newline-delimited JSON entries
+// to os.Stdout.
+func NewStdoutSink() Sink {
+	return &jsonSink{enc: json.NewEncoder(os.Stdout)}
+}
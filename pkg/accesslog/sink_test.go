@@ -0,0 +1,53 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestJsonSinkConcurrentEmitProducesValidJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	s := &jsonSink{enc: json.NewEncoder(&buf)}
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.Emit(context.Background(), Entry{RuleID: 1})
+		}()
+	}
+	wg.Wait()
+
+	scanner := bufio.NewScanner(&buf)
+	lines := 0
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %d is not valid JSON (concurrent writes interleaved?): %v", lines, err)
+		}
+		lines++
+	}
+	if lines != n {
+		t.Fatalf("expected %d entries, got %d", n, lines)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package celpolicy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+)
+
+// programCache memoizes compiled CEL programs by the SHA-256 hash of their
+// source expression, so that identical When expressions across many
+// PortRuleHTTP rules (or repeated Sanitize calls during policy updates)
+// share a single compilation.
+var programCache sync.Map // map[string]cel.Program
+
+// Compile parses, type-checks and caches expr, returning the compiled
+// program. It is safe for concurrent use. Once expr has compiled
+// successfully, later calls reuse the cached program; concurrent first
+// calls may each compile it, and only one result is kept. Failed
+// compilations are not cached.
+func Compile(expr string) (cel.Program, error) {
+	key := hashExpr(expr)
+
+	if cached, ok := programCache.Load(key); ok {
+		return cached.(cel.Program), nil
+	}
+
+	env, err := getEnv()
+	if err != nil {
+		return nil, fmt.Errorf("building CEL environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if err := checkResultType(ast); err != nil {
+		return nil, err
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("building CEL program: %w", err)
+	}
+
+	actual, _ := programCache.LoadOrStore(key, program)
+	return actual.(cel.Program), nil
+}
+
+func hashExpr(expr string) string {
+	sum := sha256.Sum256([]byte(expr))
+	return hex.EncodeToString(sum[:])
+}
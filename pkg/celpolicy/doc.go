@@ -0,0 +1,22 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package celpolicy compiles and evaluates the CEL (Common Expression
+// Language) predicates accepted by api.PortRuleHTTP.When. It declares the
+// request context variables ("request.path", "request.method",
+// "request.host", "request.headers", "request.query", "auth.claims",
+// "source.identity") that expressions are checked and evaluated against,
+// and caches compiled programs by expression so that the proxy's hot path
+// does not recompile the same predicate on every request.
+package celpolicy
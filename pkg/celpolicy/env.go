@@ -0,0 +1,102 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package celpolicy
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+// sharedEnv lazily builds and caches the CEL environment declaring the
+// variables a When expression may reference, since building a cel.Env is
+// far more expensive than compiling a single expression against it.
+var (
+	envOnce   sync.Once
+	sharedEnv *cel.Env
+	envErr    error
+)
+
+func getEnv() (*cel.Env, error) {
+	envOnce.Do(func() {
+		sharedEnv, envErr = cel.NewEnv(
+			cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("auth", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("source", cel.MapType(cel.StringType, cel.DynType)),
+		)
+	})
+	return sharedEnv, envErr
+}
+
+// RequestContext is the set of values a compiled When expression is
+// evaluated against, mirroring the "request", "auth" and "source"
+// top-level variables declared in env.
+type RequestContext struct {
+	Path    string
+	Method  string
+	Host    string
+	Headers map[string]string
+	Query   map[string]string
+
+	// AuthClaims holds the claims of a token verified by MatchJWT, or nil
+	// if no such rule applied to this request.
+	AuthClaims map[string]interface{}
+
+	// SourceIdentity is the numeric Cilium security identity of the
+	// request's source endpoint, formatted as a string.
+	SourceIdentity string
+}
+
+func (c RequestContext) toActivation() map[string]interface{} {
+	headers := make(map[string]interface{}, len(c.Headers))
+	for k, v := range c.Headers {
+		headers[k] = v
+	}
+	query := make(map[string]interface{}, len(c.Query))
+	for k, v := range c.Query {
+		query[k] = v
+	}
+	claims := make(map[string]interface{}, len(c.AuthClaims))
+	for k, v := range c.AuthClaims {
+		claims[k] = v
+	}
+
+	return map[string]interface{}{
+		"request": map[string]interface{}{
+			"path":    c.Path,
+			"method":  c.Method,
+			"host":    c.Host,
+			"headers": headers,
+			"query":   query,
+		},
+		"auth": map[string]interface{}{
+			"claims": claims,
+		},
+		"source": map[string]interface{}{
+			"identity": c.SourceIdentity,
+		},
+	}
+}
+
+// checkResultType rejects expressions that do not evaluate to a boolean,
+// since a When predicate gates whether the rule matches.
+func checkResultType(ast *cel.Ast) error {
+	if outType := ast.OutputType(); outType != cel.BoolType && outType != types.BoolType {
+		return fmt.Errorf("When expression must evaluate to a bool, got %s", outType)
+	}
+	return nil
+}
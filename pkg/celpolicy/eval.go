@@ -0,0 +1,38 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package celpolicy
+
+import "fmt"
+
+// Matches compiles (or reuses the cached compilation of) expr and
+// evaluates it against ctx, returning whether the PortRuleHTTP.When
+// predicate is satisfied for this request.
+func Matches(expr string, ctx RequestContext) (bool, error) {
+	program, err := Compile(expr)
+	if err != nil {
+		return false, err
+	}
+
+	out, _, err := program.Eval(ctx.toActivation())
+	if err != nil {
+		return false, fmt.Errorf("evaluating When expression %q: %w", expr, err)
+	}
+
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("When expression %q did not evaluate to a bool", expr)
+	}
+	return matched, nil
+}
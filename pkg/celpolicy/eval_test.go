@@ -0,0 +1,56 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package celpolicy
+
+import "testing"
+
+func TestMatches(t *testing.T) {
+	ctx := RequestContext{
+		Path:           "/v1/checkout",
+		Method:         "POST",
+		Host:           "example.com",
+		Headers:        map[string]string{"x-env": "prod"},
+		AuthClaims:     map[string]interface{}{"sub": "alice"},
+		SourceIdentity: "1234",
+	}
+
+	matched, err := Matches(`request.method == "POST" && auth.claims["sub"] == "alice"`, ctx)
+	if err != nil {
+		t.Fatalf("Matches: %v", err)
+	}
+	if !matched {
+		t.Fatal("expected expression to match")
+	}
+
+	matched, err = Matches(`request.headers["x-env"] == "staging"`, ctx)
+	if err != nil {
+		t.Fatalf("Matches: %v", err)
+	}
+	if matched {
+		t.Fatal("expected expression not to match")
+	}
+}
+
+func TestCompileRejectsNonBoolExpression(t *testing.T) {
+	if _, err := Compile(`request.path`); err == nil {
+		t.Fatal("expected an error for a When expression that does not evaluate to a bool")
+	}
+}
+
+func TestCompileRejectsInvalidExpression(t *testing.T) {
+	if _, err := Compile(`request.path ==`); err == nil {
+		t.Fatal("expected an error for a syntactically invalid When expression")
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jwtauth implements JWT verification for the api.MatchJWT policy
+// rule. It resolves the signing keys for a rule's issuer via JWKS (either
+// fetched directly from JwksUrl or discovered through a Provider), verifies
+// the token signature and standard claims, and makes the verified claims
+// available to the rest of the HTTP proxy path (e.g. for header forwarding
+// or HeaderMatch ValueFrom resolution).
+package jwtauth
@@ -0,0 +1,180 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// negativeCacheTTL bounds how long a failed JWKS fetch is remembered, so a
+// misbehaving or unreachable issuer cannot be hammered on every request.
+const negativeCacheTTL = 30 * time.Second
+
+// jwksFetcher resolves a key ID to a public key for a single issuer,
+// refreshing its view of the issuer's JWKS in the background on expiry.
+type jwksFetcher struct {
+	source jwksSource
+	ttl    time.Duration
+	client *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastErr     error
+	lastErrTime time.Time
+}
+
+// jwksSource knows how to locate the JWKS document for a rule, whether that
+// is a static URL, a provider-specific well-known endpoint, or an OIDC
+// discovery document that must itself be fetched first.
+type jwksSource interface {
+	// JWKSEndpoint returns the URL serving the JSON Web Key Set.
+	JWKSEndpoint(ctx context.Context, client *http.Client) (string, error)
+}
+
+func newJWKSFetcher(source jwksSource, ttl time.Duration) *jwksFetcher {
+	return &jwksFetcher{
+		source: source,
+		ttl:    ttl,
+		client: http.DefaultClient,
+		keys:   make(map[string]crypto.PublicKey),
+	}
+}
+
+// runBackgroundRefresh periodically refreshes the JWKS ahead of its TTL so
+// that KeyByID on the request path normally hits a warm cache instead of
+// blocking on a fetch. It returns once ctx is cancelled.
+func (f *jwksFetcher) runBackgroundRefresh(ctx context.Context) {
+	ticker := time.NewTicker(f.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = f.refresh(ctx)
+		}
+	}
+}
+
+// KeyByID returns the public key for kid, refreshing the cached JWKS if it
+// has expired. An empty kid matches the sole key when the set contains
+// exactly one.
+func (f *jwksFetcher) KeyByID(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	f.mu.RLock()
+	fresh := time.Since(f.fetchedAt) < f.ttl
+	key, ok := f.lookupLocked(kid)
+	negErr, negFresh := f.lastErr, time.Since(f.lastErrTime) < negativeCacheTTL
+	f.mu.RUnlock()
+
+	if fresh && ok {
+		return key, nil
+	}
+	// The negative cache is independent of fresh: a failed background
+	// refresh updates lastErr/lastErrTime without touching fetchedAt, so by
+	// the time an outage has produced a cached error, fresh is usually
+	// already false. Gating on negFresh alone is what makes the negative
+	// cache actually protect a sustained outage from a refresh on every
+	// request.
+	if negErr != nil && negFresh {
+		return nil, negErr
+	}
+
+	if err := f.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if key, ok := f.lookupLocked(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no JWKS key found for kid %q", kid)
+}
+
+func (f *jwksFetcher) lookupLocked(kid string) (crypto.PublicKey, bool) {
+	if kid != "" {
+		key, ok := f.keys[kid]
+		return key, ok
+	}
+	if len(f.keys) == 1 {
+		for _, key := range f.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// refresh fetches and decodes the JWKS document, populating the key cache.
+// A fetch error is cached for negativeCacheTTL to protect the issuer from
+// repeated retries on every verification attempt.
+func (f *jwksFetcher) refresh(ctx context.Context) error {
+	endpoint, err := f.source.JWKSEndpoint(ctx, f.client)
+	if err == nil {
+		err = f.fetchKeys(ctx, endpoint)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		f.lastErr = err
+		f.lastErrTime = time.Now()
+		return err
+	}
+	f.lastErr = nil
+	f.fetchedAt = time.Now()
+	return nil
+}
+
+func (f *jwksFetcher) fetchKeys(ctx context.Context, endpoint string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS from %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS from %s: unexpected status %s", endpoint, resp.Status)
+	}
+
+	var set jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decoding JWKS from %s: %w", endpoint, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.KeyID == "" || !k.Valid() {
+			continue
+		}
+		keys[k.KeyID] = k.Key
+	}
+
+	f.mu.Lock()
+	f.keys = keys
+	f.mu.Unlock()
+	return nil
+}
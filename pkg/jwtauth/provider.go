@@ -0,0 +1,131 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// jwksKeyFor returns the fetcher cache key for a rule: rules with the same
+// JwksUrl share a fetcher; rules without one share a fetcher for the GCP
+// provider's fixed JWKS, or otherwise per Issuer.
+func jwksKeyFor(rule *api.MatchJWT) (string, error) {
+	switch {
+	case rule.JwksUrl != "":
+		return "url:" + rule.JwksUrl, nil
+	case rule.Provider == api.ProviderGcp:
+		return "provider:" + string(rule.Provider), nil
+	case rule.Issuer != "":
+		return "issuer:" + rule.Issuer, nil
+	default:
+		return "", fmt.Errorf("MatchJWT rule has neither JwksUrl nor Issuer set")
+	}
+}
+
+// jwksSourceFor builds the jwksSource appropriate for a rule: a static URL
+// when JwksUrl is set, otherwise a provider- or OIDC-discovery-backed
+// source derived from the issuer.
+func jwksSourceFor(rule *api.MatchJWT) (jwksSource, error) {
+	if rule.JwksUrl != "" {
+		return staticJWKSSource(rule.JwksUrl), nil
+	}
+
+	switch rule.Provider {
+	case api.ProviderAuth0:
+		return auth0Source{issuer: rule.Issuer}, nil
+	case api.ProviderGcp:
+		return gcpSource{}, nil
+	case "":
+		if rule.Issuer == "" {
+			return nil, fmt.Errorf("MatchJWT rule has neither JwksUrl, Provider nor Issuer set")
+		}
+		return oidcDiscoverySource{issuer: rule.Issuer}, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT provider: %s", rule.Provider)
+	}
+}
+
+// staticJWKSSource is a jwksSource that always resolves to a fixed URL.
+type staticJWKSSource string
+
+func (s staticJWKSSource) JWKSEndpoint(_ context.Context, _ *http.Client) (string, error) {
+	return string(s), nil
+}
+
+// auth0Source resolves an Auth0 tenant's JWKS endpoint from its issuer,
+// following Auth0's well-known layout of <issuer>/.well-known/jwks.json.
+type auth0Source struct {
+	issuer string
+}
+
+func (s auth0Source) JWKSEndpoint(_ context.Context, _ *http.Client) (string, error) {
+	if s.issuer == "" {
+		return "", fmt.Errorf("AUTH0 provider requires Issuer to be set")
+	}
+	return strings.TrimSuffix(s.issuer, "/") + "/.well-known/jwks.json", nil
+}
+
+// gcpSource resolves Google's fixed, well-known JWKS endpoint used for
+// verifying service-account-signed and Google-identity-signed tokens.
+type gcpSource struct{}
+
+const gcpJWKSEndpoint = "https://www.googleapis.com/oauth2/v3/certs"
+
+func (gcpSource) JWKSEndpoint(_ context.Context, _ *http.Client) (string, error) {
+	return gcpJWKSEndpoint, nil
+}
+
+// oidcDiscoverySource resolves the JWKS endpoint for an arbitrary OIDC
+// issuer by fetching its "/.well-known/openid-configuration" discovery
+// document and reading the "jwks_uri" field, per the OIDC Discovery spec.
+type oidcDiscoverySource struct {
+	issuer string
+}
+
+type oidcDiscoveryDoc struct {
+	JWKSURI string `json:"jwks_uri"`
+}
+
+func (s oidcDiscoverySource) JWKSEndpoint(ctx context.Context, client *http.Client) (string, error) {
+	discoveryURL := strings.TrimSuffix(s.issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching OIDC discovery document from %s: %w", discoveryURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching OIDC discovery document from %s: unexpected status %s", discoveryURL, resp.Status)
+	}
+
+	var doc oidcDiscoveryDoc
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("decoding OIDC discovery document from %s: %w", discoveryURL, err)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("OIDC discovery document from %s is missing jwks_uri", discoveryURL)
+	}
+	return doc.JWKSURI, nil
+}
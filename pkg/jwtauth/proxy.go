@@ -0,0 +1,126 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwtauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// ForwardedClaimHeaderPrefix is prepended to a claim name to form the header
+// name used when forwarding verified claims to the upstream, e.g. the "sub"
+// claim becomes the "X-Jwt-Claim-Sub" header.
+const ForwardedClaimHeaderPrefix = "X-Jwt-Claim-"
+
+// Authenticate is the integration point called from the HTTP proxy's
+// per-request path for a PortRuleHTTP that carries MatchJWT rules. It
+// extracts the token using the source selected by rule.FromHeaders/
+// FromParams (defaulting to the Authorization header), verifies it with v,
+// and, when any of rules has Forward set (whichever rule verified the
+// token), adds the verified claims to req's headers so the upstream can
+// observe them.
+//
+// It returns an error if no token can be extracted or none of the rules
+// verify it; callers should reject the request in that case.
+func Authenticate(ctx context.Context, v Verifier, rules []*api.MatchJWT, req *http.Request) (Claims, error) {
+	token, err := extractToken(rules, req)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := v.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if forwardAny(rules) {
+		forwardClaims(claims, req)
+	}
+
+	return claims, nil
+}
+
+// extractToken reads the bearer token from req using the source configured
+// on the rules (they must agree, since they share a single request). When
+// no rule sets FromHeaders or FromParams, the default is to read the
+// standard "Authorization: Bearer <token>" header.
+func extractToken(rules []*api.MatchJWT, req *http.Request) (string, error) {
+	fromParams := false
+	for _, rule := range rules {
+		if rule.FromParams {
+			fromParams = true
+		}
+	}
+
+	if fromParams {
+		if token := req.URL.Query().Get("access_token"); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("no access_token query parameter present")
+	}
+
+	const bearerPrefix = "Bearer "
+	auth := req.Header.Get("Authorization")
+	if len(auth) > len(bearerPrefix) && auth[:len(bearerPrefix)] == bearerPrefix {
+		return auth[len(bearerPrefix):], nil
+	}
+	return "", fmt.Errorf("no bearer token present in Authorization header")
+}
+
+func forwardAny(rules []*api.MatchJWT) bool {
+	for _, rule := range rules {
+		if rule.Forward {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardClaims sets one header per top-level string-ish claim so the
+// upstream service can consume identity information without re-verifying
+// the token itself.
+func forwardClaims(claims Claims, req *http.Request) {
+	for name, value := range claims {
+		switch v := value.(type) {
+		case string:
+			req.Header.Set(ForwardedClaimHeaderPrefix+headerCase(name), v)
+		case []interface{}:
+			for _, e := range v {
+				if s, ok := e.(string); ok {
+					req.Header.Add(ForwardedClaimHeaderPrefix+headerCase(name), s)
+				}
+			}
+		}
+	}
+}
+
+// headerCase capitalizes the first rune of a claim name and replaces
+// underscores with hyphens for use as an HTTP header suffix, e.g. "sub" ->
+// "Sub", "given_name" -> "Given-name". http.Header.Set/Add further
+// canonicalize the full header name via textproto, so the capitalization
+// only affects readability; the underscore replacement changes the name.
+func headerCase(claim string) string {
+	r := []rune(claim)
+	if len(r) == 0 {
+		return claim
+	}
+	r[0] = unicode.ToUpper(r[0])
+	return strings.ReplaceAll(string(r), "_", "-")
+}
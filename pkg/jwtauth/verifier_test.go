@@ -0,0 +1,46 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwtauth
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestAnyAudienceMatches(t *testing.T) {
+	audiences := []string{"api.example.com"}
+
+	t.Run("matching audience", func(t *testing.T) {
+		claims := jwt.MapClaims{"aud": "api.example.com"}
+		if !anyAudienceMatches(claims, audiences) {
+			t.Fatal("expected a token with the configured audience to match")
+		}
+	})
+
+	t.Run("mismatched audience", func(t *testing.T) {
+		claims := jwt.MapClaims{"aud": "other.example.com"}
+		if anyAudienceMatches(claims, audiences) {
+			t.Fatal("expected a token with a different audience not to match")
+		}
+	})
+
+	t.Run("missing audience claim", func(t *testing.T) {
+		claims := jwt.MapClaims{"sub": "user-1"}
+		if anyAudienceMatches(claims, audiences) {
+			t.Fatal("a token with no aud claim must not satisfy a configured Audiences requirement")
+		}
+	})
+}
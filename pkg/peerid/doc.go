@@ -0,0 +1,23 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package peerid extracts the mTLS client identity of a request for the
+// api.PortRuleHTTP.PeerAuth match: the SPIFFE ID (or other SAN URI/DNS
+// name) of the validated client certificate, read either from Envoy's
+// "x-forwarded-client-cert" header or, when Envoy's SDS config surfaces it,
+// the downstream TLS connection's validated URI SAN. It then enforces the
+// configured TrustDomain/AllowedIDs allow-list. Enforce checks the
+// certificate identity only; this package does not combine that check with
+// the JWT verification provided by jwtauth.
+package peerid
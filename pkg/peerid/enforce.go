@@ -0,0 +1,62 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peerid
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// Enforce is the integration point called from the HTTP proxy's per-request
+// path for a PortRuleHTTP that carries a PeerAuth. It extracts the client's
+// validated identity from req (preferring the downstream TLS connection's
+// validated URI SAN, when sdsValidatedURISAN is non-empty, over parsing the
+// X-Forwarded-Client-Cert header) and checks it against auth's allow-list.
+func Enforce(auth *api.PeerAuth, sdsValidatedURISAN string, req *http.Request) error {
+	id := sdsValidatedURISAN
+	if id == "" {
+		var ok bool
+		id, ok = FromXFCC(req.Header.Get(xfccHeader))
+		if !ok {
+			// No identity was presented at all. This must be rejected
+			// whenever the rule actually requires one: either explicitly
+			// via RequireClientCert, or implicitly by configuring an
+			// AllowedIDs list, since an allow-list that silently lets
+			// certificate-less requests through is not an allow-list.
+			if auth.RequireClientCert || len(auth.AllowedIDs) > 0 {
+				return errNoClientCert
+			}
+			return nil
+		}
+	}
+
+	if auth.TrustDomain != "" && !strings.HasPrefix(id, "spiffe://"+auth.TrustDomain+"/") {
+		return fmt.Errorf("peer identity %q is not in trust domain %q", id, auth.TrustDomain)
+	}
+
+	if len(auth.AllowedIDs) == 0 {
+		return nil
+	}
+	for _, pattern := range auth.AllowedIDs {
+		if matched, _ := path.Match(pattern, id); matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("peer identity %q does not match any allowed ID", id)
+}
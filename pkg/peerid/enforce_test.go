@@ -0,0 +1,43 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peerid
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+func TestEnforceNoIdentityPresented(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+
+	t.Run("AllowedIDs set without RequireClientCert", func(t *testing.T) {
+		auth := &api.PeerAuth{AllowedIDs: []string{"spiffe://prod/ns/*/sa/*"}}
+		if err := Enforce(auth, "", req); err == nil {
+			t.Fatal("expected a request with no client certificate to be denied when AllowedIDs is configured")
+		}
+	})
+
+	t.Run("no AllowedIDs and no RequireClientCert", func(t *testing.T) {
+		auth := &api.PeerAuth{}
+		if err := Enforce(auth, "", req); err != nil {
+			t.Fatalf("expected a PeerAuth with no requirements to allow an unauthenticated request, got: %v", err)
+		}
+	})
+}
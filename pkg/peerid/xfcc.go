@@ -0,0 +1,88 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peerid
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// xfccHeader is the header Envoy sets with details of the client
+// certificate validated on the downstream mTLS connection, per
+// https://www.envoyproxy.io/docs/envoy/latest/configuration/http/http_conn_man/headers#config-http-conn-man-headers-x-forwarded-client-cert.
+const xfccHeader = "X-Forwarded-Client-Cert"
+
+// FromXFCC extracts the client identity from the value of the
+// X-Forwarded-Client-Cert header Envoy attaches to an mTLS-terminated
+// request. It prefers the "URI" SAN element (where a SPIFFE ID is carried)
+// and falls back to the first "DNS" SAN element. It returns ok=false if the
+// header is absent or carries no certificate element.
+//
+// This requires Envoy's forward_client_cert_details to be configured as
+// APPEND_FORWARD (or SANITIZE_SET, for a single mTLS-terminating hop): our
+// own listener's validated cert details must always be the last element,
+// closest to us, with any upstream-supplied elements preceding it. Reading
+// the first element instead would let an untrusted client spoof its
+// identity by prefixing a crafted element onto the header itself.
+func FromXFCC(headerValue string) (id string, ok bool) {
+	if headerValue == "" {
+		return "", false
+	}
+
+	// XFCC carries one comma-separated element per proxy hop; each element
+	// is a semicolon-separated list of Key=Value pairs. We only trust the
+	// element added by our own mTLS listener, which is the last one.
+	elements := strings.Split(headerValue, ",")
+	element := elements[len(elements)-1]
+
+	var uriSAN, dnsSAN string
+	for _, kv := range strings.Split(element, ";") {
+		k, v, found := strings.Cut(kv, "=")
+		if !found {
+			continue
+		}
+		v = strings.Trim(v, `"`)
+		switch k {
+		case "URI":
+			if decoded, err := url.QueryUnescape(v); err == nil {
+				uriSAN = decoded
+			} else {
+				uriSAN = v
+			}
+		case "DNS":
+			if dnsSAN == "" {
+				dnsSAN = v
+			}
+		}
+	}
+
+	switch {
+	case uriSAN != "":
+		return uriSAN, true
+	case dnsSAN != "":
+		return dnsSAN, true
+	default:
+		return "", false
+	}
+}
+
+// HeaderName returns the header FromXFCC expects to read, exported so
+// callers assembling the downstream proxy config know what to forward.
+func HeaderName() string {
+	return xfccHeader
+}
+
+var errNoClientCert = fmt.Errorf("no client certificate identity present in %s", xfccHeader)
@@ -0,0 +1,40 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peerid
+
+import "testing"
+
+func TestFromXFCCTrustsLastElement(t *testing.T) {
+	// A spoofed, attacker-supplied element precedes the element our own
+	// mTLS listener appended after validating the connection.
+	spoofed := `URI=spiffe://prod/ns/default/sa/attacker`
+	validated := `URI=spiffe://prod/ns/prod/sa/workload`
+	header := spoofed + "," + validated
+
+	id, ok := FromXFCC(header)
+	if !ok {
+		t.Fatal("expected an identity to be found")
+	}
+	if id != "spiffe://prod/ns/prod/sa/workload" {
+		t.Fatalf("expected the last (our own validated) element to be trusted, got %q", id)
+	}
+}
+
+func TestFromXFCCSingleElement(t *testing.T) {
+	id, ok := FromXFCC(`URI=spiffe://prod/ns/prod/sa/workload`)
+	if !ok || id != "spiffe://prod/ns/prod/sa/workload" {
+		t.Fatalf("expected single-element header to resolve, got id=%q ok=%v", id, ok)
+	}
+}
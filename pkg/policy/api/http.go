@@ -16,7 +16,12 @@ package api
 
 import (
 	"fmt"
+	"net/url"
+	"path"
 	"regexp"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/celpolicy"
 )
 
 // MismatchAction specifies what to do when there is no header match
@@ -61,6 +66,18 @@ type HeaderMatch struct {
 	//
 	// +kubebuilder:validation:Optional
 	Value string `json:"value,omitempty"`
+
+	// ValueFrom resolves the comparison value at request time from a
+	// dynamic source instead of a literal Value or Secret. Supported
+	// expressions are "jwt.claims.<path>" (a claim of the token verified by
+	// MatchJWT, dotted for nested claims), "request.metadata.<key>" (a key
+	// from Envoy dynamic metadata) and "env.<name>" (an environment
+	// variable of the proxy process), e.g.
+	// "request.metadata.labels[\"key\"]". Mutually exclusive with Value and
+	// Secret.
+	//
+	// +kubebuilder:validation:Optional
+	ValueFrom string `json:"valueFrom,omitempty"`
 }
 
 // when using a external identity provider envoy will need to fetch
@@ -98,6 +115,87 @@ type MatchJWT struct {
 	FromParams  bool         `json:"fromParams,omitempty"`
 }
 
+// RateLimitUnit is the time unit a RateLimit.Requests count applies to.
+type RateLimitUnit string
+
+const (
+	RateLimitUnitSecond RateLimitUnit = "second"
+	RateLimitUnitMinute RateLimitUnit = "minute"
+	RateLimitUnitHour   RateLimitUnit = "hour"
+)
+
+// RateLimit describes a token-bucket limit applied to requests matching a
+// PortRuleHTTP.
+type RateLimit struct {
+	// Requests is the number of requests allowed per Unit.
+	//
+	// +kubebuilder:validation:Minimum=1
+	Requests uint32 `json:"requests"`
+
+	// Unit is the time unit Requests is counted over.
+	//
+	// +kubebuilder:validation:Enum=second;minute;hour
+	Unit RateLimitUnit `json:"unit"`
+
+	// Burst is the number of requests allowed to exceed Requests/Unit
+	// momentarily, i.e. the token bucket size. Defaults to Requests when
+	// zero.
+	//
+	// +kubebuilder:validation:Optional
+	Burst uint32 `json:"burst,omitempty"`
+
+	// Key selects what requests are counted together. One of
+	// "source.identity" (the default), "header:<name>",
+	// "jwt.claim:<path>" or "client.ip".
+	//
+	// +kubebuilder:validation:Optional
+	Key string `json:"key,omitempty"`
+}
+
+// PeerAuth describes a requirement on the client's mTLS identity, matched
+// against the SPIFFE ID (or other SAN URI/DNS name) of its validated
+// certificate.
+type PeerAuth struct {
+	// RequireClientCert, if true, rejects requests whose client did not
+	// present a certificate validated by the proxy's mTLS listener.
+	//
+	// +kubebuilder:validation:Optional
+	RequireClientCert bool `json:"requireClientCert,omitempty"`
+
+	// TrustDomain restricts AllowedIDs to SPIFFE IDs within this trust
+	// domain, e.g. "example.org". Optional; when empty, AllowedIDs entries
+	// must each be a fully qualified "spiffe://<trust-domain>/..." URI or
+	// SAN value.
+	//
+	// +kubebuilder:validation:Optional
+	TrustDomain string `json:"trustDomain,omitempty"`
+
+	// AllowedIDs is a list of glob patterns (e.g.
+	// "spiffe://example.org/ns/prod/sa/*") matched against the client
+	// certificate's SPIFFE ID, or other SAN URI/DNS name, for the request
+	// to be allowed.
+	//
+	// +kubebuilder:validation:Optional
+	AllowedIDs []string `json:"allowedIDs,omitempty"`
+}
+
+// LogConfig configures access log emission for a PortRuleHTTP.
+type LogConfig struct {
+	// Redact is a list of header and JWT claim names whose value should be
+	// replaced with a SHA-256 digest in the access log instead of logged in
+	// the clear. Must be non-empty when set.
+	//
+	// +kubebuilder:validation:Optional
+	Redact []string `json:"redact,omitempty"`
+
+	// ObfuscateClientIP, if true, masks the logged client IP to its /24
+	// subnet (IPv4) or /96 subnet (IPv6) rather than logging it in full.
+	// Only valid when access logging is enabled for this rule.
+	//
+	// +kubebuilder:validation:Optional
+	ObfuscateClientIP bool `json:"obfuscateClientIP,omitempty"`
+}
+
 // PortRuleHTTP is a list of HTTP protocol constraints. All fields are
 // optional, if all fields are empty or missing, the rule does not have any
 // effect.
@@ -164,6 +262,42 @@ type PortRuleHTTP struct {
 	//
 	// +kubebuilder:validation:Optional
 	MatchJWT []*MatchJWT `json:"matchJWT,omitempty"`
+
+	// LogConfig controls how this rule's MismatchAction=LOG decisions (and
+	// any other match decision made for it) are recorded in the access
+	// log, including redaction of sensitive header/claim values.
+	//
+	// +kubebuilder:validation:Optional
+	LogConfig *LogConfig `json:"logConfig,omitempty"`
+
+	// PeerAuth matches the identity of the client's validated certificate
+	// against an allow-list of SPIFFE identities, in addition to any other
+	// match criteria of this rule. A certificate is required only when
+	// RequireClientCert is set or AllowedIDs is non-empty.
+	//
+	// +kubebuilder:validation:Optional
+	PeerAuth *PeerAuth `json:"peerAuth,omitempty"`
+
+	// RateLimit bounds how often requests matching this rule may be
+	// allowed through. When AuditMode is set, an exceeded limit is only
+	// reported for logging and the request is still allowed through.
+	//
+	// +kubebuilder:validation:Optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+
+	// When is a CEL (Common Expression Language) predicate evaluated
+	// against the request, in addition to the Path/Method/Host regex
+	// fields and HeaderMatches above. The expression is evaluated in a
+	// context exposing "request.path", "request.method", "request.host",
+	// "request.headers" (a map of header name to value), "request.query"
+	// (a map of query parameter name to value), "auth.claims" (the claims
+	// of a token verified by MatchJWT, empty if none applied) and
+	// "source.identity" (the numeric Cilium security identity of the
+	// source, as a string). A rule matches only if the regex fields (when
+	// set) and When (when set) both evaluate true.
+	//
+	// +kubebuilder:validation:Optional
+	When string `json:"when,omitempty"`
 }
 
 // Sanitize sanitizes HTTP rules. It ensures that the path and method fields
@@ -201,9 +335,184 @@ func (h *PortRuleHTTP) Sanitize() error {
 		if m.Secret != nil && m.Secret.Name == "" {
 			return fmt.Errorf("Secret name missing")
 		}
+		if m.ValueFrom != "" {
+			if m.Value != "" {
+				return fmt.Errorf("HeaderMatch %q: ValueFrom and Value are mutually exclusive", m.Name)
+			}
+			if m.Secret != nil {
+				return fmt.Errorf("HeaderMatch %q: ValueFrom and Secret are mutually exclusive", m.Name)
+			}
+			if err := validateValueFrom(m.ValueFrom); err != nil {
+				return fmt.Errorf("HeaderMatch %q: %w", m.Name, err)
+			}
+		}
+	}
+
+	for _, jwt := range h.MatchJWT {
+		if err := jwt.Sanitize(); err != nil {
+			return err
+		}
+	}
+
+	if h.When != "" {
+		if _, err := celpolicy.Compile(h.When); err != nil {
+			return fmt.Errorf("invalid When expression %q: %w", h.When, err)
+		}
+	}
+
+	if h.RateLimit != nil {
+		if err := h.RateLimit.Sanitize(); err != nil {
+			return err
+		}
+	}
+
+	if h.PeerAuth != nil {
+		if err := h.PeerAuth.Sanitize(); err != nil {
+			return err
+		}
 	}
 
-	// and about matchJWT?
+	if h.LogConfig != nil {
+		if err := h.LogConfig.Sanitize(h.logIsEnabled()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// logIsEnabled reports whether this rule will actually produce access log
+// entries, i.e. whether any HeaderMatch uses MismatchActionLog.
+func (h *PortRuleHTTP) logIsEnabled() bool {
+	for _, m := range h.HeaderMatches {
+		if m.Mismatch == MismatchActionLog {
+			return true
+		}
+	}
+	return false
+}
+
+// Sanitize sanitizes a LogConfig. It ensures Redact, if present, is
+// non-empty and contains only non-empty names, and that ObfuscateClientIP
+// is only set on a rule that actually emits access log entries.
+func (l *LogConfig) Sanitize(loggingEnabled bool) error {
+	if l.Redact != nil && len(l.Redact) == 0 {
+		return fmt.Errorf("LogConfig.Redact, if present, must not be empty")
+	}
+	for _, name := range l.Redact {
+		if name == "" {
+			return fmt.Errorf("LogConfig.Redact entries must not be empty")
+		}
+	}
+
+	if l.ObfuscateClientIP && !loggingEnabled {
+		return fmt.Errorf("LogConfig.ObfuscateClientIP requires a HeaderMatch with Mismatch: LOG")
+	}
+
+	return nil
+}
+
+// spiffeURIRegexp matches a well-formed SPIFFE ID: "spiffe://<trust-domain>
+// /<path>", per the SPIFFE-ID specification. The trust domain is a DNS-like
+// name; the path segments are not otherwise constrained here.
+var spiffeURIRegexp = regexp.MustCompile(`^spiffe://[a-zA-Z0-9.-]+(/[^/]+)+$`)
+
+// Sanitize sanitizes a PeerAuth. It ensures AllowedIDs contains only
+// syntactically valid SPIFFE URIs (or plain glob patterns, for matching
+// non-SPIFFE SAN DNS names) with valid glob syntax, and that TrustDomain,
+// if set, looks like a DNS name.
+func (p *PeerAuth) Sanitize() error {
+	if p.TrustDomain != "" {
+		if !regexp.MustCompile(`^[a-zA-Z0-9.-]+$`).MatchString(p.TrustDomain) {
+			return fmt.Errorf("Invalid PeerAuth trust domain: %s", p.TrustDomain)
+		}
+	}
+
+	for _, id := range p.AllowedIDs {
+		if _, err := path.Match(id, ""); err != nil {
+			return fmt.Errorf("Invalid PeerAuth allowed ID glob %q: %w", id, err)
+		}
+		if strings.HasPrefix(id, "spiffe://") {
+			// A glob pattern may replace path segments with "*", which the
+			// strict SPIFFE-ID grammar above does not accept; only check
+			// the literal (non-wildcarded) form for well-formedness.
+			if !strings.Contains(id, "*") && !spiffeURIRegexp.MatchString(id) {
+				return fmt.Errorf("Invalid PeerAuth allowed SPIFFE ID: %s", id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rateLimitKeyRegexp matches the well-known RateLimit.Key selectors:
+// "source.identity", "client.ip", "header:<name>" and "jwt.claim:<path>".
+var rateLimitKeyRegexp = regexp.MustCompile(`^(source\.identity|client\.ip|header:\S+|jwt\.claim:\S+)$`)
+
+// Sanitize sanitizes a RateLimit. It ensures Requests is non-zero, Unit is
+// one of the supported enum values, and Key (if set) matches one of the
+// supported selector forms.
+func (r *RateLimit) Sanitize() error {
+	if r.Requests == 0 {
+		return fmt.Errorf("RateLimit.Requests must be greater than zero")
+	}
+
+	switch r.Unit {
+	case RateLimitUnitSecond, RateLimitUnitMinute, RateLimitUnitHour:
+	default:
+		return fmt.Errorf("Invalid RateLimit unit: %s", r.Unit)
+	}
+
+	if r.Key != "" && !rateLimitKeyRegexp.MatchString(r.Key) {
+		return fmt.Errorf("Invalid RateLimit key selector: %s", r.Key)
+	}
+
+	return nil
+}
+
+// Sanitize sanitizes a MatchJWT. It ensures that the rule has enough
+// information to locate a JWKS (either a directly configured JwksUrl or a
+// known Provider), that the issuer is a well-formed URL, that the audience
+// list is not empty when configured, and that at most one "From*" source is
+// set to select where the token is read from in the request. If no source
+// is set, FromHeaders is assumed.
+func (j *MatchJWT) Sanitize() error {
+	if j.JwksUrl == "" && j.Provider == "" {
+		return fmt.Errorf("MatchJWT must set either JwksUrl or Provider")
+	}
+
+	if j.Provider != "" && j.Provider != ProviderAuth0 && j.Provider != ProviderGcp {
+		return fmt.Errorf("Invalid JWT provider: %s", j.Provider)
+	}
+
+	if j.JwksUrl == "" && j.Provider == ProviderAuth0 && j.Issuer == "" {
+		return fmt.Errorf("MatchJWT with provider %s must set Issuer or JwksUrl", j.Provider)
+	}
+
+	if j.Issuer != "" {
+		u, err := url.Parse(j.Issuer)
+		if err != nil {
+			return fmt.Errorf("Invalid JWT issuer: %s", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("JWT issuer must be an absolute URL: %s", j.Issuer)
+		}
+	}
+
+	if j.Audiences != nil && len(j.Audiences) == 0 {
+		return fmt.Errorf("MatchJWT audiences, if present, must not be empty")
+	}
+
+	sources := 0
+	if j.FromHeaders {
+		sources++
+	}
+	if j.FromParams {
+		sources++
+	}
+	if sources > 1 {
+		return fmt.Errorf("MatchJWT may set at most one of FromHeaders, FromParams")
+	}
 
 	return nil
 }
@@ -232,3 +541,26 @@ func (h *MatchJWT) strSliceCmp(a, b []string) bool {
 	}
 	return true
 }
+
+// valueFromRootRegexp matches a HeaderMatch.ValueFrom expression: one of the
+// whitelisted roots ("jwt.claims", "request.metadata", "env"), followed by a
+// dotted path of identifier segments. Each segment may optionally be
+// subscripted with a single bracketed index or quoted key, e.g.
+// "jwt.claims.groups[0]" or "request.metadata.labels[\"key\"]".
+var valueFromRootRegexp = regexp.MustCompile(
+	`^(jwt\.claims|request\.metadata|env)(\.[A-Za-z_][A-Za-z0-9_]*(\[(\d+|"[^"]*")\])?)+$`)
+
+// validateValueFrom checks that expr is a syntactically valid
+// HeaderMatch.ValueFrom expression: a whitelisted root, a dotted path of
+// identifiers, and balanced, non-nested bracket subscripts. It does not
+// check that the referenced claim, metadata key, or environment variable
+// actually exists, since that is only known at request time.
+func validateValueFrom(expr string) error {
+	if strings.Count(expr, "[") != strings.Count(expr, "]") {
+		return fmt.Errorf("unbalanced brackets in ValueFrom expression %q", expr)
+	}
+	if !valueFromRootRegexp.MatchString(expr) {
+		return fmt.Errorf("invalid ValueFrom expression %q: must start with jwt.claims, request.metadata or env, followed by a dotted path", expr)
+	}
+	return nil
+}
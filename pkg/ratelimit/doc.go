@@ -0,0 +1,19 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit enforces the api.RateLimit carried by a PortRuleHTTP.
+// It provides an in-process sharded token-bucket Limiter for single-replica
+// deployments and a Redis-backed Limiter for proxies that must share limit
+// state across replicas.
+package ratelimit
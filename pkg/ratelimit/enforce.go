@@ -0,0 +1,136 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/jwtauth"
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// Enforce is the integration point called from the HTTP proxy's per-request
+// path for a PortRuleHTTP that carries a RateLimit. It resolves the
+// configured Key selector against req (and claims, the JWT claims verified
+// for this request by a sibling MatchJWT rule, if any), checks it with l,
+// and, if the limit is exceeded, writes a 429 response with a Retry-After
+// header to w and returns false.
+//
+// trustedHops is the number of trusted reverse proxy hops in front of this
+// listener, as used to resolve a "client.ip" key from X-Forwarded-For; see
+// clientIP for details. It must reflect the deployment's actual proxy
+// chain, since trusting an unbounded X-Forwarded-For lets a client forge a
+// new rate limit bucket on every request.
+//
+// exceeded reports whether the limit was exceeded by this request,
+// independent of whether it was actually let through. When auditMode (the
+// rule's AuditMode) is set, an exceeded limit is still allowed through (matching the
+// log-only semantics AuditMode has for the other PortRuleHTTP match
+// fields), but exceeded is set so the caller can record the event in the
+// access log.
+func Enforce(ctx context.Context, l Limiter, limit *api.RateLimit, auditMode bool, sourceIdentity string, claims jwtauth.Claims, trustedHops int, w http.ResponseWriter, req *http.Request) (allowed bool, exceeded bool, err error) {
+	key, err := resolveKey(limit.Key, sourceIdentity, claims, trustedHops, req)
+	if err != nil {
+		return false, false, err
+	}
+
+	result, err := l.Allow(ctx, key, *limit)
+	if err != nil {
+		return false, false, err
+	}
+	if result.Allowed {
+		return true, false, nil
+	}
+	if auditMode {
+		return true, true, nil
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(result.RetryAfter.Seconds())+1))
+	w.WriteHeader(http.StatusTooManyRequests)
+	return false, true, nil
+}
+
+// resolveKey evaluates a RateLimit.Key selector against the current
+// request, defaulting to the request's source security identity.
+func resolveKey(selector, sourceIdentity string, claims jwtauth.Claims, trustedHops int, req *http.Request) (string, error) {
+	switch {
+	case selector == "" || selector == "source.identity":
+		return "identity:" + sourceIdentity, nil
+	case selector == "client.ip":
+		return "ip:" + clientIP(req, trustedHops), nil
+	case len(selector) > len("header:") && selector[:len("header:")] == "header:":
+		name := selector[len("header:"):]
+		return "header:" + name + ":" + req.Header.Get(name), nil
+	case len(selector) > len("jwt.claim:") && selector[:len("jwt.claim:")] == "jwt.claim:":
+		path := selector[len("jwt.claim:"):]
+		value, err := claimValue(claims, path)
+		if err != nil {
+			return "", fmt.Errorf("resolving RateLimit key %q: %w", selector, err)
+		}
+		return "jwt.claim:" + path + ":" + value, nil
+	default:
+		return "", fmt.Errorf("unsupported RateLimit key selector: %s", selector)
+	}
+}
+
+// claimValue looks up a, possibly dotted, claim path in claims, e.g. "sub"
+// or "org.id". It returns an error if the claim (or an intermediate
+// segment) is absent, since silently falling back to a shared key would
+// defeat per-identity rate limiting.
+func claimValue(claims jwtauth.Claims, path string) (string, error) {
+	var current interface{} = map[string]interface{}(claims)
+	for _, segment := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("claim path %q: %q is not an object", path, segment)
+		}
+		current, ok = m[segment]
+		if !ok {
+			return "", fmt.Errorf("claim path %q: %q not found", path, segment)
+		}
+	}
+
+	switch v := current.(type) {
+	case string:
+		return v, nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+// clientIP returns the request's client address for the "client.ip" key
+// selector. X-Forwarded-For is only trusted when trustedHops is positive,
+// in which case the address trustedHops entries from the right of the
+// X-Forwarded-For list is used (mirroring Envoy's xff_num_trusted_hops):
+// that is the address appended by the outermost trusted proxy, which an
+// external client cannot forge by stuffing extra entries onto the header.
+// With trustedHops <= 0, X-Forwarded-For is ignored entirely and
+// req.RemoteAddr (the direct TCP peer) is used.
+func clientIP(req *http.Request, trustedHops int) string {
+	if trustedHops > 0 {
+		if fwd := req.Header.Get("X-Forwarded-For"); fwd != "" {
+			addrs := strings.Split(fwd, ",")
+			idx := len(addrs) - trustedHops
+			if idx >= 0 && idx < len(addrs) {
+				return strings.TrimSpace(addrs[idx])
+			}
+		}
+	}
+	return req.RemoteAddr
+}
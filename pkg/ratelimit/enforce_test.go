@@ -0,0 +1,69 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cilium/cilium/pkg/jwtauth"
+)
+
+func TestResolveKeyJWTClaim(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+
+	claims := jwtauth.Claims{"sub": "alice"}
+	keyA, err := resolveKey("jwt.claim:sub", "", claims, 0, req)
+	if err != nil {
+		t.Fatalf("resolveKey: %v", err)
+	}
+
+	claims2 := jwtauth.Claims{"sub": "bob"}
+	keyB, err := resolveKey("jwt.claim:sub", "", claims2, 0, req)
+	if err != nil {
+		t.Fatalf("resolveKey: %v", err)
+	}
+
+	if keyA == keyB {
+		t.Fatalf("expected distinct subjects to resolve to distinct rate limit keys, both got %q", keyA)
+	}
+}
+
+func TestResolveKeyJWTClaimMissing(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+
+	if _, err := resolveKey("jwt.claim:sub", "", jwtauth.Claims{}, 0, req); err == nil {
+		t.Fatal("expected an error when the configured claim is absent from the token")
+	}
+}
+
+func TestClientIPIgnoresUntrustedForwardedFor(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+	if ip := clientIP(req, 0); ip != req.RemoteAddr {
+		t.Fatalf("expected untrusted X-Forwarded-For to be ignored, got %q", ip)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// Result is the outcome of a single Allow check.
+type Result struct {
+	// Allowed is true if the request is within the configured limit.
+	Allowed bool
+
+	// RetryAfter is how long the caller should wait before the next
+	// request is likely to be allowed. Only meaningful when !Allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter enforces a rate limit keyed by an arbitrary string (the resolved
+// value of the rule's RateLimit.Key selector). LocalLimiter uses a token
+// bucket; RedisLimiter uses a fixed-window counter.
+type Limiter interface {
+	// Allow consumes one token for key under limit, returning whether the
+	// request is allowed.
+	Allow(ctx context.Context, key string, limit api.RateLimit) (Result, error)
+}
+
+// windowFor returns the bucket refill period for a RateLimit's Unit.
+func windowFor(limit api.RateLimit) (time.Duration, error) {
+	switch limit.Unit {
+	case api.RateLimitUnitSecond:
+		return time.Second, nil
+	case api.RateLimitUnitMinute:
+		return time.Minute, nil
+	case api.RateLimitUnitHour:
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unsupported RateLimit unit: %s", limit.Unit)
+	}
+}
+
+// burstFor returns the configured burst, defaulting to Requests.
+func burstFor(limit api.RateLimit) uint32 {
+	if limit.Burst == 0 {
+		return limit.Requests
+	}
+	return limit.Burst
+}
@@ -0,0 +1,147 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// localShardCount is the number of buckets maps the in-process Limiter
+// shards its keys over, to keep lock contention low under concurrent
+// requests across many distinct keys.
+const localShardCount = 32
+
+// localIdleTTL is how long a bucket may go unused before it is evicted.
+// Without eviction, a key selector that an attacker controls (e.g.
+// "client.ip" behind an untrusted X-Forwarded-For) could otherwise grow
+// the bucket map without bound.
+const localIdleTTL = 10 * time.Minute
+
+// localEvictionInterval is how often each shard is swept for idle buckets.
+const localEvictionInterval = time.Minute
+
+// LocalLimiter is an in-process token-bucket Limiter. It does not share
+// state across proxy replicas; use RedisLimiter for that.
+type LocalLimiter struct {
+	shards [localShardCount]*localShard
+	cancel context.CancelFunc
+}
+
+type localShard struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens     float64
+	maxTokens  float64
+	refillRate float64 // tokens per second
+	updatedAt  time.Time
+}
+
+// NewLocalLimiter returns a ready-to-use in-process Limiter. It starts a
+// background goroutine that evicts buckets idle for longer than
+// localIdleTTL; call Close to stop it.
+func NewLocalLimiter() *LocalLimiter {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &LocalLimiter{cancel: cancel}
+	for i := range l.shards {
+		l.shards[i] = &localShard{buckets: make(map[string]*tokenBucket)}
+	}
+	go l.runEviction(ctx)
+	return l
+}
+
+// Close stops the background eviction goroutine started by
+// NewLocalLimiter.
+func (l *LocalLimiter) Close() {
+	l.cancel()
+}
+
+func (l *LocalLimiter) runEviction(ctx context.Context) {
+	ticker := time.NewTicker(localEvictionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.evictIdle()
+		}
+	}
+}
+
+func (l *LocalLimiter) evictIdle() {
+	cutoff := time.Now().Add(-localIdleTTL)
+	for _, shard := range l.shards {
+		shard.mu.Lock()
+		for key, bucket := range shard.buckets {
+			if bucket.updatedAt.Before(cutoff) {
+				delete(shard.buckets, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+func (l *LocalLimiter) Allow(_ context.Context, key string, limit api.RateLimit) (Result, error) {
+	window, err := windowFor(limit)
+	if err != nil {
+		return Result{}, err
+	}
+
+	shard := l.shards[shardFor(key)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	bucket, ok := shard.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{
+			tokens:     float64(burstFor(limit)),
+			maxTokens:  float64(burstFor(limit)),
+			refillRate: float64(limit.Requests) / window.Seconds(),
+			updatedAt:  time.Now(),
+		}
+		shard.buckets[key] = bucket
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	bucket.updatedAt = now
+	bucket.tokens += elapsed * bucket.refillRate
+	if bucket.tokens > bucket.maxTokens {
+		bucket.tokens = bucket.maxTokens
+	}
+
+	if bucket.tokens < 1 {
+		missing := 1 - bucket.tokens
+		retryAfter := time.Duration(missing/bucket.refillRate*float64(time.Second)) + time.Nanosecond
+		return Result{Allowed: false, RetryAfter: retryAfter}, nil
+	}
+
+	bucket.tokens--
+	return Result{Allowed: true}, nil
+}
+
+func shardFor(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % localShardCount
+}
@@ -0,0 +1,85 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// slidingWindowScript implements a fixed-window counter keyed per
+// caller-supplied window bucket: it increments the counter and, only on
+// the first increment of the window, sets its expiry. Multiple proxy
+// replicas sharing the same Redis therefore see a consistent count for the
+// window regardless of which replica handles a given request.
+const slidingWindowScript = `
+local c = redis.call('INCR', KEYS[1])
+if c == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return c
+`
+
+// RedisLimiter is a Limiter backed by Redis, sharing limit state across all
+// proxy replicas pointed at the same Redis instance.
+type RedisLimiter struct {
+	client *redis.Client
+	script *redis.Script
+}
+
+// NewRedisLimiter returns a Limiter that counts requests in client.
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{
+		client: client,
+		script: redis.NewScript(slidingWindowScript),
+	}
+}
+
+func (r *RedisLimiter) Allow(ctx context.Context, key string, limit api.RateLimit) (Result, error) {
+	window, err := windowFor(limit)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// bucket quantizes time into non-overlapping windows so that all
+	// requests within the same window share one counter key, per the
+	// well-known fixed-window rate limiting algorithm.
+	bucket := time.Now().UnixNano() / window.Nanoseconds()
+	redisKey := fmt.Sprintf("cilium:ratelimit:%s:%d", key, bucket)
+
+	count, err := r.script.Run(ctx, r.client, []string{redisKey}, window.Milliseconds()).Int64()
+	if err != nil {
+		return Result{}, fmt.Errorf("evaluating rate limit script: %w", err)
+	}
+
+	// limitValue is the steady-state threshold, matching LocalLimiter's
+	// semantics: Requests is the sustained rate enforced per window, Burst
+	// only allows a momentary ceiling above it (which a fixed-window
+	// counter cannot model without per-client token state).
+	limitValue := int64(limit.Requests)
+	if count <= limitValue {
+		return Result{Allowed: true}, nil
+	}
+
+	// The window has already been counted past the limit; retry once the
+	// current window elapses.
+	elapsed := time.Duration(time.Now().UnixNano() % window.Nanoseconds())
+	return Result{Allowed: false, RetryAfter: window - elapsed}, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package valuefrom resolves api.HeaderMatch.ValueFrom expressions
+// ("jwt.claims.<path>", "request.metadata.<key>", "env.<name>") against the
+// JWT claims verified for a request and the Envoy dynamic metadata attached
+// to it, so that HeaderMatch and the REPLACE/ADD MismatchAction can compare
+// against, or inject, a value that is only known at request time.
+package valuefrom
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/jwtauth"
+)
+
+// Context carries the per-request dynamic state that ValueFrom expressions
+// may be resolved against.
+type Context struct {
+	// Claims holds the JWT claims verified for the current request, or nil
+	// if no MatchJWT rule applied.
+	Claims jwtauth.Claims
+
+	// Metadata holds the Envoy dynamic metadata namespace emitted for the
+	// current request (e.g. from an earlier filter in the chain).
+	Metadata map[string]interface{}
+}
+
+// segment is one step of a parsed path: a map key, optionally followed by a
+// bracketed subscript, which is either a numeric index into a list-valued
+// element (e.g. "groups[0]") or a quoted string key into a second,
+// map-valued lookup (e.g. "metadata[\"key\"]").
+type segment struct {
+	key      string
+	index    int
+	subKey   string
+	hasIndex bool
+	hasSub   bool
+}
+
+// Resolve evaluates expr (already syntax-checked by
+// api.PortRuleHTTP.Sanitize) against ctx and returns the resolved string
+// value. It returns an error if the root is unknown, the referenced path is
+// absent, or an intermediate path segment is not an object. A non-string
+// final value is formatted with fmt.
+func Resolve(expr string, ctx Context) (string, error) {
+	root, path, err := splitRoot(expr)
+	if err != nil {
+		return "", err
+	}
+
+	segments, err := parsePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	switch root {
+	case "jwt.claims":
+		return resolveFrom(map[string]interface{}(ctx.Claims), segments, expr)
+	case "request.metadata":
+		return resolveFrom(ctx.Metadata, segments, expr)
+	case "env":
+		if len(segments) != 1 || segments[0].hasIndex || segments[0].hasSub {
+			return "", fmt.Errorf("invalid env ValueFrom expression %q", expr)
+		}
+		value, ok := os.LookupEnv(segments[0].key)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", segments[0].key)
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported ValueFrom root %q", root)
+	}
+}
+
+func splitRoot(expr string) (root, path string, err error) {
+	for _, candidate := range []string{"jwt.claims", "request.metadata", "env"} {
+		if expr == candidate || strings.HasPrefix(expr, candidate+".") {
+			return candidate, strings.TrimPrefix(expr, candidate+"."), nil
+		}
+	}
+	return "", "", fmt.Errorf("ValueFrom expression %q does not start with a known root", expr)
+}
+
+// parsePath splits a dotted path with optional bracket subscripts into its
+// segments, e.g. "groups[0].name" -> [{key:"groups",index:0,hasIndex:true},
+// {key:"name"}], or "metadata[\"svc\"].name" ->
+// [{key:"metadata",subKey:"svc",hasSub:true}, {key:"name"}]. A quoted
+// subscript (e.g. ["svc"]) is a second map lookup; an unquoted, numeric one
+// (e.g. [0]) indexes into a list.
+func parsePath(path string) ([]segment, error) {
+	// Split on dots outside brackets, so a quoted key such as
+	// ["app.kubernetes.io/name"] stays one part.
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				parts = append(parts, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, path[start:])
+
+	var segments []segment
+	for _, part := range parts {
+		key := part
+		idx := -1
+		subKey := ""
+		hasIndex, hasSub := false, false
+		if open := strings.IndexByte(part, '['); open >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, fmt.Errorf("unbalanced brackets in ValueFrom path %q", path)
+			}
+			key = part[:open]
+			raw := part[open+1 : len(part)-1]
+			if len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"' {
+				subKey = strings.Trim(raw, `"`)
+				hasSub = true
+			} else {
+				n, err := strconv.Atoi(raw)
+				if err != nil {
+					return nil, fmt.Errorf("invalid subscript %q in ValueFrom path %q: must be a numeric index or a quoted key", raw, path)
+				}
+				idx = n
+				hasIndex = true
+			}
+		}
+		segments = append(segments, segment{key: key, index: idx, subKey: subKey, hasIndex: hasIndex, hasSub: hasSub})
+	}
+	return segments, nil
+}
+
+func resolveFrom(root map[string]interface{}, segments []segment, expr string) (string, error) {
+	var current interface{} = root
+	for _, seg := range segments {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("ValueFrom expression %q: %q is not an object", expr, seg.key)
+		}
+		current, ok = m[seg.key]
+		if !ok {
+			return "", fmt.Errorf("ValueFrom expression %q: %q not found", expr, seg.key)
+		}
+		switch {
+		case seg.hasIndex:
+			list, ok := current.([]interface{})
+			if !ok || seg.index < 0 || seg.index >= len(list) {
+				return "", fmt.Errorf("ValueFrom expression %q: %q has no index %d", expr, seg.key, seg.index)
+			}
+			current = list[seg.index]
+		case seg.hasSub:
+			sub, ok := current.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("ValueFrom expression %q: %q is not an object", expr, seg.key)
+			}
+			current, ok = sub[seg.subKey]
+			if !ok {
+				return "", fmt.Errorf("ValueFrom expression %q: %q has no key %q", expr, seg.key, seg.subKey)
+			}
+		}
+	}
+
+	switch v := current.(type) {
+	case string:
+		return v, nil
+	case fmt.Stringer:
+		return v.String(), nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2016-2017 Authors of Cilium
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package valuefrom
+
+import "testing"
+
+func TestResolveQuotedKeySubscript(t *testing.T) {
+	ctx := Context{
+		Metadata: map[string]interface{}{
+			"labels": map[string]interface{}{
+				"svc": map[string]interface{}{
+					"name": "checkout",
+				},
+			},
+		},
+	}
+
+	got, err := Resolve(`request.metadata.labels["svc"].name`, ctx)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got != "checkout" {
+		t.Fatalf("expected %q, got %q", "checkout", got)
+	}
+}
+
+func TestResolveNumericIndexSubscript(t *testing.T) {
+	ctx := Context{
+		Claims: map[string]interface{}{
+			"groups": []interface{}{"admin", "dev"},
+		},
+	}
+
+	got, err := Resolve("jwt.claims.groups[1]", ctx)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got != "dev" {
+		t.Fatalf("expected %q, got %q", "dev", got)
+	}
+}